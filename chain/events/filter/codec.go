@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Codec is implemented by concrete Filter types (log filters, block
+// filters, pending-tx filters, ...) that want to be persisted by a durable
+// FilterStore. It lets the store serialize and rehydrate filters without
+// importing their concrete types.
+type Codec interface {
+	Filter
+
+	// Type returns a stable identifier for the concrete filter
+	// implementation, used to pick the right decoder on rehydration. It
+	// must not change across versions, or persisted filters will fail to
+	// load after an upgrade.
+	Type() string
+
+	// Marshal encodes the filter's persistent state (id, criteria, owner,
+	// last-taken, ...). It must not attempt to encode the live Subscription
+	// handed out by Subscribe.
+	Marshal() ([]byte, error)
+}
+
+// Unmarshaler reconstructs a Filter of a given Type() from bytes previously
+// produced by that type's Codec.Marshal.
+type Unmarshaler func(data []byte) (Filter, error)
+
+var (
+	codecsMu sync.Mutex
+	codecs   = map[string]Unmarshaler{}
+)
+
+// RegisterFilterCodec registers the decoder used by durable FilterStore
+// implementations to rehydrate persisted filters of the given type on
+// startup. Concrete filter packages should call this from an init func.
+func RegisterFilterCodec(typ string, dec Unmarshaler) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[typ] = dec
+}
+
+func decodeFilter(typ string, data []byte) (Filter, error) {
+	codecsMu.Lock()
+	dec, ok := codecs[typ]
+	codecsMu.Unlock()
+	if !ok {
+		return nil, xerrors.Errorf("no codec registered for filter type %q", typ)
+	}
+
+	return dec(data)
+}
@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var fakeFilterSeq uint64
+
+// fakeFilter is a minimal Filter used across this package's tests. IDs are
+// assigned from a monotonic counter rather than newFilterID/uuid so tests
+// get distinct, deterministic IDs without depending on a random source.
+type fakeFilter struct {
+	id    FilterID
+	kind  FilterKind
+	owner string
+
+	mu        sync.Mutex
+	lastTaken time.Time
+}
+
+func newFakeFilter(kind FilterKind, owner string) *fakeFilter {
+	seq := atomic.AddUint64(&fakeFilterSeq, 1)
+	var id FilterID
+	binary.BigEndian.PutUint64(id[:8], seq)
+	return &fakeFilter{id: id, kind: kind, owner: owner}
+}
+
+func (f *fakeFilter) ID() FilterID     { return f.id }
+func (f *fakeFilter) Kind() FilterKind { return f.kind }
+func (f *fakeFilter) OwnerID() string  { return f.owner }
+
+func (f *fakeFilter) LastTaken() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastTaken
+}
+
+func (f *fakeFilter) setLastTaken(t time.Time) {
+	f.mu.Lock()
+	f.lastTaken = t
+	f.mu.Unlock()
+}
+
+func (f *fakeFilter) touch() {
+	f.setLastTaken(time.Now())
+}
+
+func (f *fakeFilter) Subscribe(opts SubOptions) (Subscription, error) {
+	return NewSubscription(opts), nil
+}
+
+var _ Filter = (*fakeFilter)(nil)
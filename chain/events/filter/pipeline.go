@@ -0,0 +1,324 @@
+package filter
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+)
+
+// FilterResult is the outcome of evaluating a single sub-filter against an
+// event.
+type FilterResult int
+
+const (
+	// Fail means the event does not match and the pipeline should reject it.
+	Fail FilterResult = iota
+	// Pass means the event matches this sub-filter.
+	Pass
+	// Ignore means this sub-filter has no opinion on the event (e.g. the
+	// event isn't of a shape it understands) and evaluation should move on
+	// as if it had passed.
+	Ignore
+)
+
+// FilterFunc evaluates a single predicate against an event. Built-in
+// sub-filters (address match, topic match, ...) are FilterFuncs, and
+// callers can supply their own.
+type FilterFunc func(ctx context.Context, event interface{}) (FilterResult, error)
+
+// SubFilterParams describes one node in a Pipeline's sub-filter DAG: a named
+// predicate plus which node to run next depending on whether it passed or
+// failed. Leaving OnPass/OnFail empty makes that outcome terminal for the
+// pipeline.
+type SubFilterParams struct {
+	Name   string
+	Filter FilterFunc
+	OnPass string
+	OnFail string
+}
+
+// Pipeline is a Filter whose match logic is an ordered chain of sub-filters
+// rather than a single monolithic address+topics check. It lets callers
+// push heavy predicates (e.g. "decoded ERC-20 Transfer with value > X") down
+// into the node instead of fetching every log and filtering client-side.
+//
+// This package only evaluates a single pipeline's own sub-filter DAG (see
+// Matches); it does not dispatch events to multiple pipelines itself. A
+// caller that holds several Pipelines against one event stream (e.g. a
+// chain-notify fan-out loop) is responsible for sorting them by Priority,
+// descending, before calling Matches on each in turn.
+type Pipeline interface {
+	Filter
+
+	// PutSubFilter registers or replaces a sub-filter node. Safe to call
+	// concurrently with Matches.
+	PutSubFilter(SubFilterParams) error
+
+	// Priority orders this pipeline relative to others evaluated against
+	// the same event stream; higher runs first. Enforcing that order
+	// across pipelines is the caller's responsibility, not this package's.
+	Priority() uint64
+}
+
+var ErrUnknownSubFilter = xerrors.New("unknown sub-filter name")
+
+type filterPipeline struct {
+	id       FilterID
+	kind     FilterKind
+	owner    string
+	priority uint64
+	entry    string
+
+	mu         sync.Mutex
+	lastTaken  time.Time
+	sub        *boundedSubscription
+	subFilters map[string]SubFilterParams
+}
+
+var _ Pipeline = (*filterPipeline)(nil)
+
+// NewPipeline builds an empty Pipeline that evaluates sub-filters starting
+// at entry. Sub-filters are added with PutSubFilter before the pipeline is
+// installed in a FilterStore.
+func NewPipeline(id FilterID, kind FilterKind, owner string, priority uint64, entry string) Pipeline {
+	return &filterPipeline{
+		id:         id,
+		kind:       kind,
+		owner:      owner,
+		priority:   priority,
+		entry:      entry,
+		subFilters: make(map[string]SubFilterParams),
+	}
+}
+
+func (p *filterPipeline) ID() FilterID     { return p.id }
+func (p *filterPipeline) Kind() FilterKind { return p.kind }
+func (p *filterPipeline) OwnerID() string  { return p.owner }
+func (p *filterPipeline) Priority() uint64 { return p.priority }
+
+func (p *filterPipeline) LastTaken() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastTaken
+}
+
+func (p *filterPipeline) Subscribe(opts SubOptions) (Subscription, error) {
+	sub := NewSubscription(opts)
+
+	p.mu.Lock()
+	old := p.sub
+	p.sub = sub
+	p.lastTaken = time.Now()
+	p.mu.Unlock()
+
+	// A re-subscribe replaces the pipeline's subscription outright; close
+	// the one it's replacing so its pump goroutine and channel don't leak.
+	if old != nil {
+		old.Close()
+	}
+
+	return sub, nil
+}
+
+func (p *filterPipeline) FullSince() (time.Time, bool) {
+	p.mu.Lock()
+	sub := p.sub
+	p.mu.Unlock()
+	if sub == nil {
+		return time.Time{}, false
+	}
+	return sub.FullSince()
+}
+
+func (p *filterPipeline) PutSubFilter(params SubFilterParams) error {
+	if params.Name == "" {
+		return xerrors.New("sub-filter name must not be empty")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subFilters[params.Name] = params
+	return nil
+}
+
+// Matches walks the sub-filter DAG starting at the pipeline's entry node,
+// following OnPass/OnFail to the next node until it reaches a node with no
+// next step, at which point that node's result is the pipeline's result. A
+// Fail with no OnFail short-circuits the walk immediately.
+func (p *filterPipeline) Matches(ctx context.Context, event interface{}) (FilterResult, error) {
+	p.mu.Lock()
+	name := p.entry
+	// Copy the entries rather than the map header: PutSubFilter can run
+	// concurrently with Matches (the API explicitly allows adding
+	// sub-filters after install), and reading a live map while it's being
+	// written is a race even though the map variable itself was captured
+	// under the lock.
+	subFilters := make(map[string]SubFilterParams, len(p.subFilters))
+	for k, v := range p.subFilters {
+		subFilters[k] = v
+	}
+	p.mu.Unlock()
+
+	visited := make(map[string]struct{})
+	result := Pass
+	sub := p.subscription()
+
+	for name != "" {
+		if _, seen := visited[name]; seen {
+			return Fail, xerrors.Errorf("sub-filter DAG cycle detected at %q", name)
+		}
+		visited[name] = struct{}{}
+
+		sf, ok := subFilters[name]
+		if !ok {
+			return Fail, xerrors.Errorf("%w: %q", ErrUnknownSubFilter, name)
+		}
+
+		res, err := sf.Filter(ctx, event)
+		if err != nil {
+			return Fail, err
+		}
+		result = res
+
+		switch res {
+		case Pass, Ignore:
+			name = sf.OnPass
+		case Fail:
+			if sf.OnFail == "" {
+				return Fail, nil
+			}
+			name = sf.OnFail
+		}
+	}
+
+	if result == Pass && sub != nil {
+		sub.Publish(event)
+	}
+
+	return result, nil
+}
+
+func (p *filterPipeline) subscription() *boundedSubscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sub
+}
+
+// LogEvent is the shape built-in sub-filters expect an event to implement.
+// Concrete log event types elsewhere in the node can satisfy it directly so
+// built-ins never need to import them.
+type LogEvent interface {
+	EventAddress() address.Address
+	EventTopics() [][]byte
+	EventBloom() []byte
+	EventData() []byte // CBOR-encoded payload
+}
+
+// AddressMatchFilter passes events emitted by one of addrs. An empty addrs
+// passes everything.
+func AddressMatchFilter(addrs []address.Address) FilterFunc {
+	set := make(map[address.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		set[a] = struct{}{}
+	}
+
+	return func(_ context.Context, event interface{}) (FilterResult, error) {
+		le, ok := event.(LogEvent)
+		if !ok {
+			return Ignore, nil
+		}
+		if len(set) == 0 {
+			return Pass, nil
+		}
+		if _, ok := set[le.EventAddress()]; ok {
+			return Pass, nil
+		}
+		return Fail, nil
+	}
+}
+
+// TopicMatchFilter passes events that carry at least one of topics. An
+// empty topics passes everything.
+func TopicMatchFilter(topics [][]byte) FilterFunc {
+	return func(_ context.Context, event interface{}) (FilterResult, error) {
+		le, ok := event.(LogEvent)
+		if !ok {
+			return Ignore, nil
+		}
+		if len(topics) == 0 {
+			return Pass, nil
+		}
+		for _, want := range topics {
+			for _, got := range le.EventTopics() {
+				if string(want) == string(got) {
+					return Pass, nil
+				}
+			}
+		}
+		return Fail, nil
+	}
+}
+
+// BloomPreCheckFilter cheaply rejects events whose bloom filter cannot
+// possibly contain key, so more expensive sub-filters further down the
+// chain never run against events that are guaranteed not to match. It never
+// produces a false negative: a Pass here is not itself proof of a match.
+func BloomPreCheckFilter(key []byte) FilterFunc {
+	return func(_ context.Context, event interface{}) (FilterResult, error) {
+		le, ok := event.(LogEvent)
+		if !ok {
+			return Ignore, nil
+		}
+		if bloomMayContain(le.EventBloom(), key) {
+			return Pass, nil
+		}
+		return Fail, nil
+	}
+}
+
+// bloomMayContain tests a 3-hash bit-array bloom filter built over the same
+// key space as key. It only ever answers "definitely absent" or "maybe
+// present".
+func bloomMayContain(bloom, key []byte) bool {
+	if len(bloom) == 0 {
+		return true // no bloom recorded; can't rule the event out
+	}
+
+	h := sha256.Sum256(key)
+	bits := len(bloom) * 8
+	for i := 0; i < 3; i++ {
+		idx := (int(h[i*2])<<8 | int(h[i*2+1])) % bits
+		if bloom[idx/8]&(1<<(idx%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CBORPredicateFilter decodes an event's payload as a generic CBOR value
+// and passes it through predicate, letting callers match on decoded fields
+// (e.g. an ERC-20 Transfer's value) instead of raw bytes.
+func CBORPredicateFilter(predicate func(val interface{}) bool) FilterFunc {
+	return func(_ context.Context, event interface{}) (FilterResult, error) {
+		le, ok := event.(LogEvent)
+		if !ok {
+			return Ignore, nil
+		}
+
+		var val interface{}
+		if err := cbor.Unmarshal(le.EventData(), &val); err != nil {
+			return Fail, xerrors.Errorf("decode event payload: %w", err)
+		}
+
+		if predicate(val) {
+			return Pass, nil
+		}
+		return Fail, nil
+	}
+}
@@ -0,0 +1,116 @@
+package filter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func constFilter(res FilterResult) FilterFunc {
+	return func(_ context.Context, _ interface{}) (FilterResult, error) {
+		return res, nil
+	}
+}
+
+// newTestPipeline returns the concrete *filterPipeline so tests can reach
+// Matches directly, which isn't part of the exported Pipeline interface.
+func newTestPipeline(id FilterID, kind FilterKind, owner string, priority uint64, entry string) *filterPipeline {
+	return NewPipeline(id, kind, owner, priority, entry).(*filterPipeline)
+}
+
+func TestPipelineMatchesWalksOnPassOnFail(t *testing.T) {
+	p := newTestPipeline(FilterID{1}, FilterKindLogs, "alice", 0, "start")
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "start", Filter: constFilter(Pass), OnPass: "mid"}))
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "mid", Filter: constFilter(Fail), OnFail: "fallback"}))
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "fallback", Filter: constFilter(Pass)}))
+
+	res, err := p.Matches(context.Background(), struct{}{})
+	require.NoError(t, err)
+	require.Equal(t, Pass, res)
+}
+
+func TestPipelineMatchesFailShortCircuitsWithNoOnFail(t *testing.T) {
+	p := newTestPipeline(FilterID{2}, FilterKindLogs, "alice", 0, "start")
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "start", Filter: constFilter(Fail)}))
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "unreached", Filter: func(context.Context, interface{}) (FilterResult, error) {
+		t.Fatal("unreached sub-filter must not run")
+		return Fail, nil
+	}}))
+
+	res, err := p.Matches(context.Background(), struct{}{})
+	require.NoError(t, err)
+	require.Equal(t, Fail, res)
+}
+
+func TestPipelineMatchesUnknownSubFilter(t *testing.T) {
+	p := newTestPipeline(FilterID{3}, FilterKindLogs, "alice", 0, "missing")
+	_, err := p.Matches(context.Background(), struct{}{})
+	require.ErrorIs(t, err, ErrUnknownSubFilter)
+}
+
+func TestPipelineMatchesDetectsCycle(t *testing.T) {
+	p := newTestPipeline(FilterID{4}, FilterKindLogs, "alice", 0, "a")
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "a", Filter: constFilter(Pass), OnPass: "b"}))
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "b", Filter: constFilter(Pass), OnPass: "a"}))
+
+	_, err := p.Matches(context.Background(), struct{}{})
+	require.Error(t, err)
+}
+
+// TestPipelinePutSubFilterConcurrentWithMatches exercises the fix for a data
+// race between Matches reading p.subFilters and PutSubFilter writing it --
+// the API explicitly allows adding sub-filters after a pipeline is already
+// installed and being matched against.
+func TestPipelinePutSubFilterConcurrentWithMatches(t *testing.T) {
+	p := newTestPipeline(FilterID{5}, FilterKindLogs, "alice", 0, "start")
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "start", Filter: constFilter(Pass)}))
+
+	stop := make(chan struct{})
+	putterDone := make(chan struct{})
+	go func() {
+		defer close(putterDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = p.PutSubFilter(SubFilterParams{Name: "extra", Filter: constFilter(Pass)})
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			_, _ = p.Matches(context.Background(), struct{}{})
+		}
+	}()
+	wg.Wait()
+
+	close(stop)
+	<-putterDone
+}
+
+func TestPipelineSubscribeClosesPrevious(t *testing.T) {
+	p := NewPipeline(FilterID{6}, FilterKindLogs, "alice", 0, "start")
+	require.NoError(t, p.PutSubFilter(SubFilterParams{Name: "start", Filter: constFilter(Pass)}))
+
+	first, err := p.Subscribe(SubOptions{Capacity: 1})
+	require.NoError(t, err)
+
+	second, err := p.Subscribe(SubOptions{Capacity: 1})
+	require.NoError(t, err)
+	require.True(t, first != second, "re-Subscribe must hand back a new subscription")
+
+	select {
+	case _, open := <-first.Out():
+		require.False(t, open, "previous subscription's Out channel should be closed on re-Subscribe")
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for previous subscription to close")
+	}
+}
@@ -12,22 +12,41 @@ import (
 
 type Filter interface {
 	ID() FilterID
+	Kind() FilterKind
+	OwnerID() string // opaque identifier of the installing client, e.g. a JWT subject or remote address; empty if unauthenticated
 	LastTaken() time.Time
-	SetSubChannel(chan<- interface{})
-	ClearSubChannel()
+	// Subscribe hands the caller a bounded, backpressure-aware event
+	// buffer instead of a raw channel, so a slow consumer shows up as
+	// rising drop counts rather than stalling the filter's producer.
+	Subscribe(opts SubOptions) (Subscription, error)
 }
 
+// FilterKind identifies which of the eth_newFilter family of RPC calls
+// installed a Filter, so stores can apply per-kind quotas and TTLs instead
+// of treating the whole pool as one undifferentiated budget.
+type FilterKind string
+
+const (
+	FilterKindLogs       FilterKind = "log"
+	FilterKindBlocks     FilterKind = "block"
+	FilterKindPendingTxs FilterKind = "pending-tx"
+)
+
 type FilterStore interface {
 	Add(context.Context, Filter) error
 	Get(context.Context, FilterID) (Filter, error)
 	Remove(context.Context, FilterID) error
 	NotTakenSince(when time.Time) []Filter // returns a list of filters that have not had their collected results taken
+	ListByKind(kind FilterKind) []Filter   // returns the live filters of a given kind
+	NotTakenSinceByKind(kind FilterKind, when time.Time) []Filter
 }
 
 var (
 	ErrFilterAlreadyRegistered = errors.New("filter already registered")
 	ErrFilterNotFound          = errors.New("filter not found")
 	ErrMaximumNumberOfFilters  = errors.New("maximum number of filters registered")
+	ErrOwnerQuotaExceeded      = errors.New("owner has reached its maximum number of filters")
+	ErrRateLimited             = errors.New("rate limited")
 )
 
 type FilterID [32]byte // compatible with EthHash
@@ -42,18 +61,28 @@ func newFilterID() (FilterID, error) {
 	return id, nil
 }
 
+// MaxFilters caps the number of live filters of a single FilterKind. A kind
+// absent from the map is left unbounded, so callers that only care about
+// one kind's budget don't have to enumerate the rest.
+type MaxFilters map[FilterKind]int
+
 type memFilterStore struct {
-	max     int
+	maxPerKind MaxFilters
+
 	mu      sync.Mutex
 	filters map[FilterID]Filter
 }
 
 var _ FilterStore = (*memFilterStore)(nil)
 
-func NewMemFilterStore(maxFilters int) FilterStore {
+// NewMemFilterStore builds an in-memory FilterStore that enforces a maximum
+// number of live filters per FilterKind (e.g. pending-tx filters tend to
+// need a much smaller ceiling than log filters), rather than one global cap
+// that lets a flood of one kind starve the others.
+func NewMemFilterStore(maxPerKind MaxFilters) FilterStore {
 	return &memFilterStore{
-		max:     maxFilters,
-		filters: make(map[FilterID]Filter),
+		maxPerKind: maxPerKind,
+		filters:    make(map[FilterID]Filter),
 	}
 }
 
@@ -61,7 +90,7 @@ func (m *memFilterStore) Add(_ context.Context, f Filter) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.filters) >= m.max {
+	if max, ok := m.maxPerKind[f.Kind()]; ok && m.countOfKind(f.Kind()) >= max {
 		return ErrMaximumNumberOfFilters
 	}
 
@@ -72,6 +101,17 @@ func (m *memFilterStore) Add(_ context.Context, f Filter) error {
 	return nil
 }
 
+// countOfKind must be called with m.mu held.
+func (m *memFilterStore) countOfKind(kind FilterKind) int {
+	n := 0
+	for _, f := range m.filters {
+		if f.Kind() == kind {
+			n++
+		}
+	}
+	return n
+}
+
 func (m *memFilterStore) Get(_ context.Context, id FilterID) (Filter, error) {
 	m.mu.Lock()
 	f, found := m.filters[id]
@@ -106,3 +146,31 @@ func (m *memFilterStore) NotTakenSince(when time.Time) []Filter {
 
 	return res
 }
+
+func (m *memFilterStore) ListByKind(kind FilterKind) []Filter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []Filter
+	for _, f := range m.filters {
+		if f.Kind() == kind {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
+
+func (m *memFilterStore) NotTakenSinceByKind(kind FilterKind, when time.Time) []Filter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res []Filter
+	for _, f := range m.filters {
+		if f.Kind() == kind && f.LastTaken().Before(when) {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// codecFakeFilter is a fakeFilter that also implements Codec, so it can be
+// round-tripped through a BoltFilterStore.
+type codecFakeFilter struct {
+	*fakeFilter
+}
+
+const codecFakeFilterType = "test-codec-fake"
+
+func newCodecFakeFilter(kind FilterKind, owner string) *codecFakeFilter {
+	return &codecFakeFilter{fakeFilter: newFakeFilter(kind, owner)}
+}
+
+func (f *codecFakeFilter) Type() string { return codecFakeFilterType }
+
+// Marshal encodes just enough to verify round-tripping: the filter's id
+// followed by its owner string. Kind is not persisted since this test double
+// only ever exercises FilterKindLogs.
+func (f *codecFakeFilter) Marshal() ([]byte, error) {
+	buf := make([]byte, 32+len(f.owner))
+	copy(buf, f.id[:])
+	copy(buf[32:], f.owner)
+	return buf, nil
+}
+
+func decodeCodecFakeFilter(data []byte) (Filter, error) {
+	var id FilterID
+	copy(id[:], data[:32])
+	owner := string(data[32:])
+	return &codecFakeFilter{fakeFilter: &fakeFilter{id: id, kind: FilterKindLogs, owner: owner}}, nil
+}
+
+func init() {
+	RegisterFilterCodec(codecFakeFilterType, decodeCodecFakeFilter)
+}
+
+func TestBoltFilterStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.db")
+
+	fs1, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+
+	f := newCodecFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, fs1.Add(context.Background(), f))
+	require.NoError(t, fs1.Close())
+
+	fs2, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+	defer fs2.Close()
+
+	got, err := fs2.Get(context.Background(), f.ID())
+	require.NoError(t, err)
+	require.Equal(t, "alice", got.OwnerID())
+	require.Equal(t, FilterKindLogs, got.Kind())
+}
+
+func TestBoltFilterStoreRemovePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.db")
+
+	fs1, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+
+	f := newCodecFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, fs1.Add(context.Background(), f))
+	require.NoError(t, fs1.Remove(context.Background(), f.ID()))
+	require.NoError(t, fs1.Close())
+
+	fs2, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+	defer fs2.Close()
+
+	_, err = fs2.Get(context.Background(), f.ID())
+	require.ErrorIs(t, err, ErrFilterNotFound)
+}
+
+func TestBoltFilterStoreAddRejectsNonCodec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.db")
+
+	fs, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.Error(t, fs.Add(context.Background(), newFakeFilter(FilterKindLogs, "alice")))
+}
+
+func TestBoltFilterStoreMaxPerKind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.db")
+
+	fs, err := NewBoltFilterStore(path, MaxFilters{FilterKindLogs: 1})
+	require.NoError(t, err)
+	defer fs.Close()
+
+	require.NoError(t, fs.Add(context.Background(), newCodecFakeFilter(FilterKindLogs, "alice")))
+	require.ErrorIs(t, fs.Add(context.Background(), newCodecFakeFilter(FilterKindLogs, "bob")), ErrMaximumNumberOfFilters)
+}
+
+func TestBoltFilterStoreNotTakenSinceByKind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.db")
+
+	fs, err := NewBoltFilterStore(path, nil)
+	require.NoError(t, err)
+	defer fs.Close()
+
+	f := newCodecFakeFilter(FilterKindLogs, "alice")
+	f.setLastTaken(time.Now().Add(-time.Hour))
+	require.NoError(t, fs.Add(context.Background(), f))
+
+	res := fs.NotTakenSinceByKind(FilterKindLogs, time.Now().Add(-time.Minute))
+	require.Len(t, res, 1)
+	require.Equal(t, f.ID(), res[0].ID())
+}
@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInstrumentedFilterStoreCollectNoDuplicateLabels exercises the fix for
+// filter_age_seconds: Collect used to emit one sample per live filter under
+// the same "kind" label, which a real Gather rejects as a duplicate the
+// moment two filters of the same kind are live at once.
+func TestInstrumentedFilterStoreCollectNoDuplicateLabels(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	reg := prometheus.NewRegistry()
+	s := NewInstrumentedFilterStore(inner, reg)
+	ctx := context.Background()
+
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "bob")))
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindBlocks, "alice")))
+
+	_, err := reg.Gather()
+	require.NoError(t, err)
+}
+
+func TestInstrumentedFilterStoreAddRejectedReasons(t *testing.T) {
+	ctx := context.Background()
+
+	dup := NewInstrumentedFilterStore(NewMemFilterStore(nil), prometheus.NewRegistry())
+	f := newFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, dup.Add(ctx, f))
+	require.ErrorIs(t, dup.Add(ctx, f), ErrFilterAlreadyRegistered)
+
+	maxed := NewInstrumentedFilterStore(NewMemFilterStore(MaxFilters{FilterKindLogs: 1}), prometheus.NewRegistry())
+	require.NoError(t, maxed.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.ErrorIs(t, maxed.Add(ctx, newFakeFilter(FilterKindLogs, "bob")), ErrMaximumNumberOfFilters)
+}
+
+func TestInstrumentedFilterStoreEvictIdle(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	reg := prometheus.NewRegistry()
+	s := NewInstrumentedFilterStore(inner, reg)
+	ctx := context.Background()
+
+	f := newFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, s.Add(ctx, f))
+
+	s.EvictIdle(ctx, time.Now().Add(time.Hour))
+
+	_, err := inner.Get(ctx, f.ID())
+	require.ErrorIs(t, err, ErrFilterNotFound)
+}
@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// QuotaPolicy configures the per-owner limits enforced by a
+// quotaFilterStore. MaxPerOwner of zero means no cap on the number of live
+// filters; InstallRPS/PollRPS of zero means no rate limit on the
+// corresponding operation.
+type QuotaPolicy struct {
+	MaxPerOwner int
+	InstallRPS  float64
+	PollRPS     float64
+}
+
+// limiterIdleTTL is how long an owner's rate limiter may sit unused before
+// it becomes a pruning candidate. It bounds installLimiters/pollLimiters
+// growth for a client that cycles through owner IDs (e.g. rotating
+// remote-addrs) instead of reusing one.
+const limiterIdleTTL = 10 * time.Minute
+
+// pruneEvery amortizes the cost of sweeping idle limiters: rather than scan
+// on every call, only do it once every pruneEvery calls to allow.
+const pruneEvery = 256
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+type quotaFilterStore struct {
+	inner  FilterStore
+	policy QuotaPolicy
+
+	mu              sync.Mutex
+	ownerCount      map[string]int
+	installLimiters map[string]*limiterEntry
+	pollLimiters    map[string]*limiterEntry
+	allowCalls      uint64
+}
+
+var _ FilterStore = (*quotaFilterStore)(nil)
+
+// NewQuotaFilterStore wraps inner with a per-owner ceiling on live filters
+// plus a token-bucket rate limit on filter installation and polling, so a
+// single buggy or malicious JSON-RPC client cannot exhaust the shared filter
+// pool or hammer the node with eth_getFilterChanges calls. Quota violations
+// surface as ErrOwnerQuotaExceeded or ErrRateLimited so the RPC layer can
+// translate them into proper JSON-RPC errors.
+func NewQuotaFilterStore(inner FilterStore, policy QuotaPolicy) FilterStore {
+	return &quotaFilterStore{
+		inner:           inner,
+		policy:          policy,
+		ownerCount:      make(map[string]int),
+		installLimiters: make(map[string]*limiterEntry),
+		pollLimiters:    make(map[string]*limiterEntry),
+	}
+}
+
+func (q *quotaFilterStore) Add(ctx context.Context, f Filter) error {
+	owner := f.OwnerID()
+
+	if !q.allow(q.installLimiters, q.policy.InstallRPS, owner) {
+		return ErrRateLimited
+	}
+
+	q.mu.Lock()
+	if q.policy.MaxPerOwner > 0 && q.ownerCount[owner] >= q.policy.MaxPerOwner {
+		q.mu.Unlock()
+		return ErrOwnerQuotaExceeded
+	}
+	// Reserve the slot before releasing the lock, so two concurrent Adds
+	// for the same owner can't both pass the check above and overshoot
+	// MaxPerOwner. Rolled back below if inner.Add fails.
+	q.ownerCount[owner]++
+	q.mu.Unlock()
+
+	if err := q.inner.Add(ctx, f); err != nil {
+		q.mu.Lock()
+		q.decrefLocked(owner)
+		q.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (q *quotaFilterStore) Get(ctx context.Context, id FilterID) (Filter, error) {
+	f, err := q.inner.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.allow(q.pollLimiters, q.policy.PollRPS, f.OwnerID()) {
+		return nil, ErrRateLimited
+	}
+
+	return f, nil
+}
+
+func (q *quotaFilterStore) Remove(ctx context.Context, id FilterID) error {
+	f, err := q.inner.Get(ctx, id)
+	owner, haveOwner := "", false
+	if err == nil {
+		owner, haveOwner = f.OwnerID(), true
+	}
+
+	if err := q.inner.Remove(ctx, id); err != nil {
+		return err
+	}
+
+	if haveOwner {
+		q.mu.Lock()
+		q.decrefLocked(owner)
+		q.mu.Unlock()
+	}
+
+	return nil
+}
+
+// decrefLocked must be called with q.mu held. It drops owner's count back
+// towards zero and deletes the entry once it reaches zero, so an owner that
+// currently holds no filters leaves no trace in ownerCount.
+func (q *quotaFilterStore) decrefLocked(owner string) {
+	if q.ownerCount[owner] <= 1 {
+		delete(q.ownerCount, owner)
+		return
+	}
+	q.ownerCount[owner]--
+}
+
+func (q *quotaFilterStore) NotTakenSince(when time.Time) []Filter {
+	return q.inner.NotTakenSince(when)
+}
+
+func (q *quotaFilterStore) ListByKind(kind FilterKind) []Filter {
+	return q.inner.ListByKind(kind)
+}
+
+func (q *quotaFilterStore) NotTakenSinceByKind(kind FilterKind, when time.Time) []Filter {
+	return q.inner.NotTakenSinceByKind(kind, when)
+}
+
+// allow reports whether owner may proceed under the rate limiter keyed by
+// limiters, lazily creating one on first use. A non-positive rps disables
+// the check entirely.
+func (q *quotaFilterStore) allow(limiters map[string]*limiterEntry, rps float64, owner string) bool {
+	if rps <= 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	q.allowCalls++
+	if q.allowCalls%pruneEvery == 0 {
+		q.pruneLimitersLocked(limiters)
+	}
+
+	entry, ok := limiters[owner]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(rate.Limit(rps), int(rps)+1)}
+		limiters[owner] = entry
+	}
+	entry.lastUsed = time.Now()
+	lim := entry.limiter
+	q.mu.Unlock()
+
+	return lim.Allow()
+}
+
+// pruneLimitersLocked must be called with q.mu held. It discards limiters
+// that haven't been used in limiterIdleTTL, so a client that cycles through
+// owner IDs doesn't grow installLimiters/pollLimiters without bound.
+func (q *quotaFilterStore) pruneLimitersLocked(limiters map[string]*limiterEntry) {
+	cutoff := time.Now().Add(-limiterIdleTTL)
+	for owner, e := range limiters {
+		if e.lastUsed.Before(cutoff) {
+			delete(limiters, owner)
+		}
+	}
+}
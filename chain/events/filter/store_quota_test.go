@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaFilterStoreMaxPerOwner(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	s := NewQuotaFilterStore(inner, QuotaPolicy{MaxPerOwner: 2})
+	ctx := context.Background()
+
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.ErrorIs(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")), ErrOwnerQuotaExceeded)
+
+	// A different owner has its own, independent budget.
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "bob")))
+}
+
+func TestQuotaFilterStoreRemoveFreesSlot(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	s := NewQuotaFilterStore(inner, QuotaPolicy{MaxPerOwner: 1})
+	ctx := context.Background()
+
+	f := newFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, s.Add(ctx, f))
+	require.ErrorIs(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")), ErrOwnerQuotaExceeded)
+
+	require.NoError(t, s.Remove(ctx, f.ID()))
+
+	qs := s.(*quotaFilterStore)
+	qs.mu.Lock()
+	_, tracked := qs.ownerCount["alice"]
+	qs.mu.Unlock()
+	require.False(t, tracked, "owner with zero filters should leave no trace in ownerCount")
+
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+}
+
+// TestQuotaFilterStoreAddConcurrentDoesNotOvershoot exercises the fix for a
+// TOCTOU race in Add: two goroutines racing the same owner against a
+// MaxPerOwner of 1 must not both succeed.
+func TestQuotaFilterStoreAddConcurrentDoesNotOvershoot(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	s := NewQuotaFilterStore(inner, QuotaPolicy{MaxPerOwner: 1})
+	ctx := context.Background()
+
+	const n = 50
+	results := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = s.Add(ctx, newFakeFilter(FilterKindLogs, "alice"))
+		}(i)
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, err := range results {
+		if err == nil {
+			ok++
+		} else {
+			require.ErrorIs(t, err, ErrOwnerQuotaExceeded)
+		}
+	}
+	require.EqualValues(t, 1, ok)
+	require.Len(t, inner.ListByKind(FilterKindLogs), 1)
+}
+
+func TestQuotaFilterStoreInstallRateLimit(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	s := NewQuotaFilterStore(inner, QuotaPolicy{InstallRPS: 1})
+	ctx := context.Background()
+
+	// The limiter's burst is rps+1, so the first two installs succeed and
+	// the third is rejected before the bucket has had time to refill.
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	require.ErrorIs(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")), ErrRateLimited)
+}
+
+func TestQuotaFilterStorePruneLimitersLocked(t *testing.T) {
+	inner := NewMemFilterStore(nil)
+	s := NewQuotaFilterStore(inner, QuotaPolicy{InstallRPS: 100})
+	qs := s.(*quotaFilterStore)
+
+	qs.mu.Lock()
+	qs.installLimiters["stale"] = &limiterEntry{lastUsed: time.Time{}}
+	qs.pruneLimitersLocked(qs.installLimiters)
+	_, tracked := qs.installLimiters["stale"]
+	qs.mu.Unlock()
+
+	require.False(t, tracked, "idle limiter entries must be pruned")
+}
@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFilterStorePerKindQuota(t *testing.T) {
+	s := NewMemFilterStore(MaxFilters{FilterKindLogs: 1, FilterKindBlocks: 0})
+	ctx := context.Background()
+
+	require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindLogs, "alice")))
+	err := s.Add(ctx, newFakeFilter(FilterKindLogs, "alice"))
+	require.ErrorIs(t, err, ErrMaximumNumberOfFilters)
+
+	// FilterKindPendingTxs is absent from maxPerKind entirely, so it's
+	// unbounded -- unlike FilterKindBlocks above, whose explicit zero means
+	// a cap of zero, not "no limit".
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Add(ctx, newFakeFilter(FilterKindPendingTxs, "bob")))
+	}
+	require.Len(t, s.ListByKind(FilterKindPendingTxs), 5)
+}
+
+func TestMemFilterStoreAddDuplicate(t *testing.T) {
+	s := NewMemFilterStore(nil)
+	ctx := context.Background()
+
+	f := newFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, s.Add(ctx, f))
+	require.ErrorIs(t, s.Add(ctx, f), ErrFilterAlreadyRegistered)
+}
+
+func TestMemFilterStoreGetRemove(t *testing.T) {
+	s := NewMemFilterStore(nil)
+	ctx := context.Background()
+
+	f := newFakeFilter(FilterKindLogs, "alice")
+	require.NoError(t, s.Add(ctx, f))
+
+	got, err := s.Get(ctx, f.ID())
+	require.NoError(t, err)
+	require.Equal(t, f, got)
+
+	require.NoError(t, s.Remove(ctx, f.ID()))
+	_, err = s.Get(ctx, f.ID())
+	require.ErrorIs(t, err, ErrFilterNotFound)
+	require.ErrorIs(t, s.Remove(ctx, f.ID()), ErrFilterNotFound)
+}
+
+func TestMemFilterStoreNotTakenSinceByKind(t *testing.T) {
+	s := NewMemFilterStore(nil)
+	ctx := context.Background()
+
+	stale := newFakeFilter(FilterKindLogs, "alice")
+	stale.setLastTaken(time.Now().Add(-time.Hour))
+	fresh := newFakeFilter(FilterKindLogs, "alice")
+	fresh.touch()
+	other := newFakeFilter(FilterKindBlocks, "alice")
+	other.setLastTaken(time.Now().Add(-time.Hour))
+
+	require.NoError(t, s.Add(ctx, stale))
+	require.NoError(t, s.Add(ctx, fresh))
+	require.NoError(t, s.Add(ctx, other))
+
+	cutoff := time.Now().Add(-time.Minute)
+	res := s.NotTakenSinceByKind(FilterKindLogs, cutoff)
+	require.Len(t, res, 1)
+	require.Equal(t, stale.ID(), res[0].ID())
+}
@@ -0,0 +1,263 @@
+package filter
+
+import (
+	"sync"
+	"time"
+)
+
+// DropPolicy controls what a Subscription does with an incoming event when
+// its buffer is already at capacity.
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room.
+	DropOldest DropPolicy = iota
+	// DropNewest discards the incoming event, leaving the buffer untouched.
+	DropNewest
+	// Coalesce overwrites the most recently buffered event with the
+	// incoming one. Intended for block/head events, where only the latest
+	// value matters to a slow consumer.
+	Coalesce
+)
+
+// Event is whatever a Filter hands to its Subscription; concrete filter
+// types decide what it actually is (a decoded log, a tipset, a txid, ...).
+type Event = interface{}
+
+// EventSizer is implemented by events that know their own encoded size, so
+// a Subscription can enforce a byte budget in addition to (or instead of) a
+// count budget. Events that don't implement it only count against Capacity.
+type EventSizer interface {
+	EventSize() int
+}
+
+// SubOptions configures the bounded buffer returned by Filter.Subscribe.
+type SubOptions struct {
+	Capacity   int // max buffered events by count; defaults to 1 if <= 0
+	MaxBytes   int // max buffered events by total EventSize(); 0 = unbounded
+	DropPolicy DropPolicy
+}
+
+// Subscription is the bounded, backpressure-aware event buffer a Filter
+// hands to whatever is draining it (typically the eth_getFilterChanges
+// poll loop or a websocket subscription). It replaces handing out a raw,
+// policy-free channel: the producer (e.g. the chain-notify loop) can always
+// publish without blocking, and a slow consumer shows up as rising Dropped
+// and HighWaterMark counts instead of a silent hang.
+type Subscription interface {
+	// Out is the channel to drain published events from. It is closed when
+	// the subscription is closed.
+	Out() <-chan Event
+	// Dropped is the cumulative count of events discarded by the drop
+	// policy because the buffer was full.
+	Dropped() uint64
+	// HighWaterMark is the largest number of events ever buffered at once.
+	HighWaterMark() int
+	// FullSince reports when the buffer most recently became continuously
+	// full, or ok=false if it isn't full right now. A store's idle-GC pass
+	// can treat "full for longer than T" as a reason to evict a filter
+	// whose consumer has stopped reading entirely.
+	FullSince() (t time.Time, ok bool)
+	// Close stops delivery and closes Out. Safe to call more than once.
+	Close()
+}
+
+// boundedSubscription is the Subscription implementation returned by
+// NewSubscription; concrete Filter types publish into it with Publish.
+type boundedSubscription struct {
+	opts SubOptions
+
+	mu        sync.Mutex
+	buf       []Event
+	bytes     int
+	dropped   uint64
+	highWater int
+	fullSince time.Time
+	closed    bool
+
+	out       chan Event
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Subscription = (*boundedSubscription)(nil)
+
+// NewSubscription builds a Subscription with the given options and starts
+// its delivery pump. Concrete Filter types should create one in Subscribe
+// and feed it via Publish as new events arrive.
+func NewSubscription(opts SubOptions) *boundedSubscription {
+	if opts.Capacity <= 0 {
+		opts.Capacity = 1
+	}
+
+	s := &boundedSubscription{
+		opts: opts,
+		out:  make(chan Event),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go s.pump()
+	return s
+}
+
+func (s *boundedSubscription) Out() <-chan Event { return s.out }
+
+func (s *boundedSubscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *boundedSubscription) HighWaterMark() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.highWater
+}
+
+func (s *boundedSubscription) FullSince() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fullSince.IsZero() {
+		return time.Time{}, false
+	}
+	return s.fullSince, true
+}
+
+func (s *boundedSubscription) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+	})
+}
+
+// Publish offers event to the subscription's buffer, applying its drop
+// policy if the buffer is already at capacity. It never blocks, which is
+// the point: a slow consumer must never stall the producer (typically the
+// chain-notify loop feeding every installed filter).
+func (s *boundedSubscription) Publish(event Event) {
+	size := eventSize(event)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	if s.isFull(size) {
+		switch s.opts.DropPolicy {
+		case DropNewest:
+			s.dropped++
+			s.markFull()
+			return
+		case Coalesce:
+			if len(s.buf) > 0 {
+				s.bytes -= eventSize(s.buf[len(s.buf)-1])
+				s.buf[len(s.buf)-1] = event
+				s.bytes += size
+				s.dropped++
+			} else {
+				s.buf = append(s.buf, event)
+				s.bytes += size
+				if len(s.buf) > s.highWater {
+					s.highWater = len(s.buf)
+				}
+			}
+			s.markFull()
+			s.signal()
+			return
+		default: // DropOldest
+			// Keep dropping the oldest entry until there's room for the
+			// incoming event, not just once: a single oversized event (or
+			// MaxBytes shrinking relative to what's buffered) can otherwise
+			// still push s.bytes past MaxBytes after only one eviction.
+			for len(s.buf) > 0 && s.isFull(size) {
+				old := s.buf[0]
+				s.buf = s.buf[1:]
+				s.bytes -= eventSize(old)
+				s.dropped++
+			}
+		}
+	}
+
+	s.buf = append(s.buf, event)
+	s.bytes += size
+	if len(s.buf) > s.highWater {
+		s.highWater = len(s.buf)
+	}
+	s.markFull()
+	s.signal()
+}
+
+// isFull must be called with s.mu held.
+func (s *boundedSubscription) isFull(incomingSize int) bool {
+	if len(s.buf) >= s.opts.Capacity {
+		return true
+	}
+	return s.opts.MaxBytes > 0 && s.bytes+incomingSize > s.opts.MaxBytes
+}
+
+// markFull must be called with s.mu held, after the buffer has settled.
+func (s *boundedSubscription) markFull() {
+	if s.isFull(0) {
+		if s.fullSince.IsZero() {
+			s.fullSince = time.Now()
+		}
+	} else {
+		s.fullSince = time.Time{}
+	}
+}
+
+func (s *boundedSubscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *boundedSubscription) pump() {
+	defer close(s.out)
+
+	for {
+		s.mu.Lock()
+		if len(s.buf) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		event := s.buf[0]
+		s.buf = s.buf[1:]
+		s.bytes -= eventSize(event)
+		s.markFull()
+		s.mu.Unlock()
+
+		select {
+		case s.out <- event:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func eventSize(event Event) int {
+	if sz, ok := event.(EventSizer); ok {
+		return sz.EventSize()
+	}
+	return 0
+}
+
+// Backpressured is implemented by Filters that can report whether their
+// subscription's buffer is currently full, so a store's idle-GC pass can
+// evict a filter whose consumer has stopped reading entirely instead of
+// only ones whose owner stopped polling for results altogether.
+type Backpressured interface {
+	Filter
+	FullSince() (t time.Time, ok bool)
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sizedEvent struct {
+	tag  int
+	size int
+}
+
+func (e sizedEvent) EventSize() int { return e.size }
+
+func drainAvailable(s Subscription, n int) []Event {
+	var out []Event
+	for i := 0; i < n; i++ {
+		select {
+		case ev := <-s.Out():
+			out = append(out, ev)
+		case <-time.After(time.Second):
+			return out
+		}
+	}
+	return out
+}
+
+func TestSubscriptionDropOldestByCount(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 2, DropPolicy: DropOldest})
+	defer s.Close()
+
+	s.Publish(sizedEvent{tag: 1})
+	s.Publish(sizedEvent{tag: 2})
+	s.Publish(sizedEvent{tag: 3}) // should drop tag 1
+
+	got := drainAvailable(s, 2)
+	require.Len(t, got, 2)
+	require.EqualValues(t, 2, got[0].(sizedEvent).tag)
+	require.EqualValues(t, 3, got[1].(sizedEvent).tag)
+	require.EqualValues(t, 1, s.Dropped())
+}
+
+// TestSubscriptionDropOldestBoundsByBytes exercises the fix for Publish's
+// byte-budget accounting: a single event bigger than several buffered ones
+// combined must evict enough of them to stay within MaxBytes, not just the
+// single oldest entry.
+func TestSubscriptionDropOldestBoundsByBytes(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 10, MaxBytes: 10, DropPolicy: DropOldest})
+	defer s.Close()
+
+	s.Publish(sizedEvent{tag: 1, size: 3})
+	s.Publish(sizedEvent{tag: 2, size: 3})
+	s.Publish(sizedEvent{tag: 3, size: 3})
+	// Incoming event would push bytes to 6+9=15 if only one eviction
+	// happened; it must evict until the 9-byte incomer fits under 10.
+	s.Publish(sizedEvent{tag: 4, size: 9})
+
+	s.mu.Lock()
+	bytes := s.bytes
+	buf := append([]Event(nil), s.buf...)
+	s.mu.Unlock()
+
+	require.LessOrEqual(t, bytes, 10)
+	require.Len(t, buf, 1)
+	require.EqualValues(t, 4, buf[0].(sizedEvent).tag)
+}
+
+func TestSubscriptionDropNewest(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 1, DropPolicy: DropNewest})
+	defer s.Close()
+
+	s.Publish(sizedEvent{tag: 1})
+	s.Publish(sizedEvent{tag: 2})
+
+	got := drainAvailable(s, 1)
+	require.Len(t, got, 1)
+	require.EqualValues(t, 1, got[0].(sizedEvent).tag)
+	require.EqualValues(t, 1, s.Dropped())
+}
+
+func TestSubscriptionCoalesce(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 1, DropPolicy: Coalesce})
+	defer s.Close()
+
+	s.Publish(sizedEvent{tag: 1})
+	s.Publish(sizedEvent{tag: 2})
+
+	got := drainAvailable(s, 1)
+	require.Len(t, got, 1)
+	require.EqualValues(t, 2, got[0].(sizedEvent).tag)
+	require.EqualValues(t, 1, s.Dropped())
+}
+
+func TestSubscriptionFullSince(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 1, DropPolicy: DropNewest})
+	defer s.Close()
+
+	_, full := s.FullSince()
+	require.False(t, full)
+
+	s.Publish(sizedEvent{tag: 1})
+	s.Publish(sizedEvent{tag: 2}) // buffer now full, dropped
+
+	_, full = s.FullSince()
+	require.True(t, full)
+}
+
+func TestSubscriptionCloseClosesOut(t *testing.T) {
+	s := NewSubscription(SubOptions{Capacity: 1})
+	s.Close()
+	s.Close() // must be safe to call twice
+
+	select {
+	case _, open := <-s.Out():
+		require.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Out to close")
+	}
+}